@@ -0,0 +1,93 @@
+package thumbnailer
+
+import "sort"
+
+// prefixLen is the number of leading bytes used to key the matcher dispatch
+// table. Most signatures in this package are deterministic within their
+// first couple of bytes, which keeps collision buckets short without
+// requiring a full trie.
+const prefixLen = 2
+
+// PrefixMatcher may optionally be implemented by a Matcher whose signature
+// begins with a fixed, unmasked byte sequence. When present, the dispatcher
+// uses Prefix() to place the Matcher in a first-bytes lookup table instead
+// of the residual linear scan list, so the common "this file matches
+// nothing" case costs a couple of map lookups plus a scan of the residual
+// list, instead of a scan over every registered Matcher.
+type PrefixMatcher interface {
+	Matcher
+	// Prefix returns the Matcher's fixed leading bytes, or nil if it has
+	// none (e.g. its first bytes are masked, or its signature sits at a
+	// non-zero offset). Must return the same value on every call.
+	Prefix() []byte
+}
+
+var (
+	// prefixDispatch indexes Matchers by their first prefixLen bytes (or
+	// fewer, if a Matcher's own Prefix() is shorter).
+	prefixDispatch = map[string][]Matcher{}
+
+	// residualMatchers holds every Matcher that doesn't implement
+	// PrefixMatcher, or whose Prefix() is empty - e.g. mp4Sig, whose
+	// leading bytes are a variable box size rather than a fixed signature.
+	// Always part of the candidate list, merged with the dispatch table's
+	// hits in registration order.
+	residualMatchers []Matcher
+
+	// registrationOrder records the order in which addToDispatch saw each
+	// Matcher, regardless of which bucket it landed in. candidatesFor sorts
+	// by this so that merging the prefix-keyed and residual buckets doesn't
+	// reorder ambiguous Matchers relative to the old full linear scan - a
+	// Matcher's position in the bucket it's filed under says nothing about
+	// its priority against a Matcher filed under a different bucket.
+	registrationOrder = map[Matcher]int{}
+)
+
+// addToDispatch files m under its declared Prefix(), or into
+// residualMatchers if it has none. Called once per Matcher, alongside the
+// existing append to the flat matchers slice.
+func addToDispatch(m Matcher) {
+	registrationOrder[m] = len(registrationOrder)
+
+	pm, ok := m.(PrefixMatcher)
+	if !ok {
+		residualMatchers = append(residualMatchers, m)
+		return
+	}
+	p := pm.Prefix()
+	if len(p) == 0 {
+		residualMatchers = append(residualMatchers, m)
+		return
+	}
+	if len(p) > prefixLen {
+		p = p[:prefixLen]
+	}
+	key := string(p)
+	prefixDispatch[key] = append(prefixDispatch[key], m)
+}
+
+// candidatesFor returns the Matchers worth trying against data: those filed
+// under data's 1- and 2-byte prefixes, plus the residual list, restored to
+// registration order. A Matcher's bucket is only a filing detail for the
+// dispatch table lookup - it must never change whether that Matcher beats
+// or loses to another ambiguous Matcher (e.g. mp4Sig, which has no fixed
+// Prefix() and so is residual, against the "mov" exactSig, which does)
+// relative to the old full linear scan over matchers.
+func candidatesFor(data []byte) []Matcher {
+	var b1, b2 []Matcher
+	if len(data) >= 1 {
+		b1 = prefixDispatch[string(data[:1])]
+	}
+	if len(data) >= prefixLen {
+		b2 = prefixDispatch[string(data[:prefixLen])]
+	}
+
+	out := make([]Matcher, 0, len(b1)+len(b2)+len(residualMatchers))
+	out = append(out, b1...)
+	out = append(out, b2...)
+	out = append(out, residualMatchers...)
+	sort.Slice(out, func(i, j int) bool {
+		return registrationOrder[out[i]] < registrationOrder[out[j]]
+	})
+	return out
+}
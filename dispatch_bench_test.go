@@ -0,0 +1,44 @@
+package thumbnailer
+
+import (
+	"bytes"
+	"testing"
+)
+
+// nonMatchingData never matches any built-in Matcher, exercising the worst
+// case for MIME sniffing: every Matcher has to be tried before giving up.
+var nonMatchingData = bytes.Repeat([]byte{0x42, 0x99}, 256)
+
+// linearMatch reproduces the pre-dispatch-table behavior of scanning every
+// registered Matcher in order, for comparison in the benchmarks below.
+func linearMatch(data []byte) (mime, ext string) {
+	for _, m := range matchers {
+		mime, ext = m.Match(data)
+		if mime != "" {
+			return
+		}
+	}
+	return "", ""
+}
+
+func dispatchMatch(data []byte) (mime, ext string) {
+	for _, m := range candidatesFor(data) {
+		mime, ext = m.Match(data)
+		if mime != "" {
+			return
+		}
+	}
+	return "", ""
+}
+
+func BenchmarkLinearScanNonMatching(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		linearMatch(nonMatchingData)
+	}
+}
+
+func BenchmarkPrefixDispatchNonMatching(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		dispatchMatch(nonMatchingData)
+	}
+}
@@ -0,0 +1,58 @@
+package thumbnailer
+
+import "testing"
+
+// TestCandidatesForPreservesRegistrationOrder guards against a regression
+// where merging the prefix-dispatch buckets ahead of residualMatchers
+// silently overrode the original linear-scan priority. mp4Sig has no
+// Prefix() (its leading bytes are a variable box size) and so is always
+// filed in residualMatchers, but it's registered before the "mov" exactSig,
+// which does have a fixed Prefix() and lands in the prefix table. An ftyp
+// box carrying an MP4 brand matches both; mp4Sig must still win.
+func TestCandidatesForPreservesRegistrationOrder(t *testing.T) {
+	data := append([]byte{0x00, 0x00, 0x00, 0x14}, []byte("ftypisom0000pad4")...)
+
+	var mime, ext string
+	for _, m := range candidatesFor(data) {
+		mime, ext = m.Match(data)
+		if mime != "" {
+			break
+		}
+	}
+
+	if mime != "video/mp4" || ext != "mp4" {
+		t.Fatalf("got (%q, %q), want (\"video/mp4\", \"mp4\")", mime, ext)
+	}
+}
+
+// TestCandidatesForLeadingWhitespace guards against a regression where
+// whitespace-prefixed HTML/XML was never matched: tagSig and xmlSig skip
+// leading ASCII whitespace before comparing, so the dispatch table - which
+// only ever keys on data's literal first bytes - filed them somewhere the
+// lookup for whitespace-prefixed input never reaches.
+func TestCandidatesForLeadingWhitespace(t *testing.T) {
+	cases := []struct {
+		name, data, wantMime, wantExt string
+	}{
+		{"leading spaces before html", "  <html>", "text/html", "html"},
+		{"leading newline before xml prologue", "\n<?xml version=\"1.0\"?>", "text/xml", "xml"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := []byte(c.data)
+
+			var mime, ext string
+			for _, m := range candidatesFor(data) {
+				mime, ext = m.Match(data)
+				if mime != "" {
+					break
+				}
+			}
+
+			if mime != c.wantMime || ext != c.wantExt {
+				t.Fatalf("got (%q, %q), want (%q, %q)", mime, ext, c.wantMime, c.wantExt)
+			}
+		})
+	}
+}
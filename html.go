@@ -0,0 +1,9 @@
+package thumbnailer
+
+// processHTML is the fallback for text/html sources. This package ships no
+// HTML renderer of its own - callers that need HTML thumbnails (e.g. a
+// headless-browser screenshot) should register one with RegisterProcessor
+// for "text/html", which takes priority over this fallback.
+func processHTML(src Source, opts Options) (Source, Thumbnail, error) {
+	return src, Thumbnail{}, UnsupportedMIMEError(src.Mime)
+}
@@ -0,0 +1,84 @@
+package thumbnailer
+
+// Kind groups related Matchers - and the MIME types they detect - into a
+// broad file category. Callers can restrict detection to a subset of Kinds
+// via Options.AcceptedKinds, e.g. to only ever sniff images and reject
+// everything else outright.
+type Kind string
+
+// Built-in Kinds. User-registered Matchers may use these or define their own.
+const (
+	KindImage    Kind = "image"
+	KindVideo    Kind = "video"
+	KindAudio    Kind = "audio"
+	KindDocument Kind = "document"
+	KindArchive  Kind = "archive"
+	KindFont     Kind = "font"
+)
+
+// kindedMatcher pairs a Matcher with the Kind of file it detects. Used to
+// build the built-in matcher table in mimes.go.
+type kindedMatcher struct {
+	kind Kind
+	m    Matcher
+}
+
+var (
+	// matchers is the flat, priority-ordered list of every registered
+	// Matcher. detectMimeType does not scan it directly; it looks Matchers up
+	// through the dispatch table in dispatch.go, which is filled alongside
+	// it. Populated from builtinMatchers at init and extended by
+	// RegisterMatcher/RegisterKind.
+	matchers []Matcher
+
+	// matcherKinds records the Kind a Matcher was registered under, if any.
+	// Matchers added via plain RegisterMatcher have no entry and are never
+	// excluded by an Options.AcceptedKinds restriction.
+	matcherKinds = map[Matcher]Kind{}
+
+	// kindMatchers indexes registered Matchers by Kind for MatchersByKind.
+	kindMatchers = map[Kind][]Matcher{}
+)
+
+func init() {
+	for _, km := range builtinMatchers {
+		addKindedMatcher(km.kind, km.m)
+	}
+}
+
+func addKindedMatcher(k Kind, m Matcher) {
+	matchers = append(matchers, m)
+	matcherKinds[m] = k
+	kindMatchers[k] = append(kindMatchers[k], m)
+	addToDispatch(m)
+}
+
+// RegisterKind adds an extra Matcher to the default set, the same way
+// RegisterMatcher does, and additionally classifies it under Kind k, so it
+// can be retrieved with MatchersByKind and is subject to Options.AcceptedKinds
+// filtering.
+// Not safe to use concurrently with file processing.
+func RegisterKind(k Kind, m Matcher) {
+	addKindedMatcher(k, m)
+}
+
+// MatchersByKind returns all registered Matchers - built-in and
+// user-registered via RegisterKind - classified under Kind k.
+func MatchersByKind(k Kind) []Matcher {
+	return kindMatchers[k]
+}
+
+// acceptedByKind reports whether a Matcher classified under k may run, given
+// an Options.AcceptedKinds restriction. An empty accepted list, or a Matcher
+// with no recorded Kind, is never restricted.
+func acceptedByKind(k Kind, accepted []Kind) bool {
+	if len(accepted) == 0 || k == "" {
+		return true
+	}
+	for _, a := range accepted {
+		if a == k {
+			return true
+		}
+	}
+	return false
+}
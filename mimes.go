@@ -9,68 +9,101 @@ import (
 
 // Matching code partially adapted from "net/http/sniff.go"
 
-// Mime type prefix magic number matchers and canonical extensions
-var matchers = []Matcher{
+// builtinMatchers lists the Matchers this package ships with, grouped by the
+// Kind of file they detect. The flat matchers slice and the kindMatchers
+// index are both derived from this at init time - see kinds.go.
+var builtinMatchers = []kindedMatcher{
 	// Probably most common types, this library will be used for, first.
 	// More expensive checks are also positioned lower.
-	&exactSig{"jpg", "image/jpeg", []byte("\xFF\xD8\xFF")},
-	&exactSig{"png", "image/png", []byte("\x89\x50\x4E\x47\x0D\x0A\x1A\x0A")},
-	&exactSig{"gif", "image/gif", []byte("GIF87a")},
-	&exactSig{"gif", "image/gif", []byte("GIF89a")},
-	&maskedSig{
+	{KindImage, &exactSig{"jpg", "image/jpeg", []byte("\xFF\xD8\xFF")}},
+	{KindImage, &exactSig{"png", "image/png", []byte("\x89\x50\x4E\x47\x0D\x0A\x1A\x0A")}},
+	{KindImage, &exactSig{"gif", "image/gif", []byte("GIF87a")}},
+	{KindImage, &exactSig{"gif", "image/gif", []byte("GIF89a")}},
+	{KindImage, &maskedSig{
 		"webp",
 		"image/webp",
 		[]byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF\xFF\xFF"),
 		[]byte("RIFF\x00\x00\x00\x00WEBPVP"),
-	},
-	&maskedSig{
+	}},
+	{KindVideo, &maskedSig{
 		"ogg",
 		"application/ogg",
 		[]byte("OggS\x00"),
 		[]byte("\x4F\x67\x67\x53\x00"),
-	},
-	&webmOrMKVSig{},
-	&exactSig{"pdf", "application/pdf", []byte("%PDF-")},
-	&maskedSig{
+	}},
+	{KindVideo, &webmOrMKVSig{}},
+	{KindDocument, &exactSig{"pdf", "application/pdf", []byte("%PDF-")}},
+	{KindAudio, &maskedSig{
 		"mp3",
 		"audio/mpeg",
 		[]byte("\xFF\xFF\xFF"),
 		[]byte("ID3"),
-	},
-	&mp4Sig{},
-	&exactSig{"aac", "audio/aac", []byte("ÿñ")},
-	&exactSig{"aac", "audio/aac", []byte("ÿù")},
-	&exactSig{"bmp", "image/bmp", []byte("BM")},
-	&maskedSig{
+	}},
+	{KindVideo, &mp4Sig{}},
+	{KindAudio, &exactSig{"aac", "audio/aac", []byte("ÿñ")}},
+	{KindAudio, &exactSig{"aac", "audio/aac", []byte("ÿù")}},
+	{KindImage, &exactSig{"bmp", "image/bmp", []byte("BM")}},
+	{KindAudio, &maskedSig{
 		"wav",
 		"audio/wave",
 		[]byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF"),
 		[]byte("RIFF\x00\x00\x00\x00WAVE"),
-	},
-	&maskedSig{
+	}},
+	{KindVideo, &maskedSig{
 		"avi",
 		"video/avi",
 		[]byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF"),
 		[]byte("RIFF\x00\x00\x00\x00AVI "),
-	},
-	&exactSig{"psd", "image/photoshop", []byte("8BPS")},
-	&exactSig{"flac", "audio/x-flac", []byte("fLaC")},
-	&exactSig{"tiff", "image/tiff", []byte("II*\x00")},
-	&exactSig{"tiff", "image/tiff", []byte("MM\x00*")},
-	&exactSig{"mov", "video/quicktime", []byte("\x00\x00\x00\x14ftyp")},
-	&exactSig{
+	}},
+	{KindImage, &exactSig{"psd", "image/photoshop", []byte("8BPS")}},
+	{KindAudio, &exactSig{"flac", "audio/x-flac", []byte("fLaC")}},
+	{KindImage, &exactSig{"tiff", "image/tiff", []byte("II*\x00")}},
+	{KindImage, &exactSig{"tiff", "image/tiff", []byte("MM\x00*")}},
+	{KindVideo, &exactSig{"mov", "video/quicktime", []byte("\x00\x00\x00\x14ftyp")}},
+	{KindVideo, &exactSig{
 		"wmv",
 		"video/x-ms-wmv",
 		[]byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11, 0xA6, 0xD9},
-	},
-	&exactSig{"flv", "video/x-flv", []byte("FLV\x01")},
-	&exactSig{"ico", "image/x-icon", []byte("\x00\x00\x01\x00")},
-	&maskedSig{
+	}},
+	{KindVideo, &exactSig{"flv", "video/x-flv", []byte("FLV\x01")}},
+	{KindImage, &exactSig{"ico", "image/x-icon", []byte("\x00\x00\x01\x00")}},
+	{KindAudio, &maskedSig{
 		"midi",
 		"audio/midi",
 		[]byte("\xFF\xFF\xFF\xFF\xFF\xFF\xFF\xFF"),
 		[]byte("MThd\x00\x00\x00\x06"),
-	},
+	}},
+	{KindAudio, &maskedSig{
+		"aiff",
+		"audio/aiff",
+		[]byte("\xFF\xFF\xFF\xFF\x00\x00\x00\x00\xFF\xFF\xFF\xFF"),
+		[]byte("FORM\x00\x00\x00\x00AIFF"),
+	}},
+	{KindAudio, &exactSig{"au", "audio/basic", []byte(".snd")}},
+
+	// Office Open XML and EPUB are zip containers, so they must be checked
+	// ahead of the generic "zip" matcher below to be told apart.
+	{KindDocument, &zipEntrySig{"epub", "application/epub+zip", "mimetypeapplication/epub+zip"}},
+	{KindDocument, &zipEntrySig{"docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "word/"}},
+	{KindDocument, &zipEntrySig{"xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xl/"}},
+	{KindDocument, &zipEntrySig{"pptx", "application/vnd.openxmlformats-officedocument.presentationml.presentation", "ppt/"}},
+
+	// Archives
+	{KindArchive, &exactSig{"zip", "application/zip", []byte("PK\x03\x04")}},
+	{KindArchive, &exactSig{"zip", "application/zip", []byte("PK\x05\x06")}},
+	{KindArchive, &exactSig{"rar", "application/x-rar-compressed", []byte("Rar!\x1A\x07\x00")}},
+	{KindArchive, &exactSig{"rar", "application/x-rar-compressed", []byte("Rar!\x1A\x07\x01\x00")}},
+	{KindArchive, &exactSig{"gz", "application/gzip", []byte("\x1F\x8B\x08")}},
+	{KindArchive, &offsetSig{"tar", "application/x-tar", 257, []byte("ustar")}},
+	{KindArchive, &exactSig{"7z", "application/x-7z-compressed", []byte("7z\xBC\xAF\x27\x1C")}},
+	{KindArchive, &exactSig{"xz", "application/x-xz", []byte("\xFD7zXZ\x00")}},
+	{KindArchive, &exactSig{"bz2", "application/x-bzip2", []byte("BZh")}},
+
+	// Fonts
+	{KindFont, &exactSig{"ttf", "font/ttf", []byte("\x00\x01\x00\x00")}},
+	{KindFont, &exactSig{"otf", "font/otf", []byte("OTTO")}},
+	{KindFont, &exactSig{"woff", "font/woff", []byte("wOFF")}},
+	{KindFont, &exactSig{"woff2", "font/woff2", []byte("wOF2")}},
 }
 
 var (
@@ -99,13 +132,20 @@ func (e *exactSig) Match(data []byte) (string, string) {
 	return "", ""
 }
 
+// Prefix implements PrefixMatcher. An exact signature's whole byte sequence
+// is deterministic, so it's also its own dispatch prefix.
+func (e *exactSig) Prefix() []byte { return e.sig }
+
 type maskedSig struct {
 	ext, mime string
 	mask, pat []byte
 }
 
 func (m *maskedSig) Match(data []byte) (string, string) {
-	if len(data) < len(m.mask) {
+	// Clause 4 of the WHATWG pattern matching algorithm requires the mask
+	// and pattern to be of equal length; a mismatch here is a bug in the
+	// Matcher's construction, not a match.
+	if len(m.mask) != len(m.pat) || len(data) < len(m.mask) {
 		return "", ""
 	}
 	for i, mask := range m.mask {
@@ -117,6 +157,22 @@ func (m *maskedSig) Match(data []byte) (string, string) {
 	return m.mime, m.ext
 }
 
+// Prefix implements PrefixMatcher, but only when the mask's leading
+// prefixLen bytes are fully set (0xFF) - i.e. actually deterministic.
+// Otherwise the Matcher falls back to the residual linear scan.
+func (m *maskedSig) Prefix() []byte {
+	n := prefixLen
+	if n > len(m.mask) {
+		n = len(m.mask)
+	}
+	for i := 0; i < n; i++ {
+		if m.mask[i] != 0xFF {
+			return nil
+		}
+	}
+	return m.pat[:n]
+}
+
 type webmOrMKVSig struct{}
 
 func (webmOrMKVSig) Match(data []byte) (string, string) {
@@ -132,8 +188,66 @@ func (webmOrMKVSig) Match(data []byte) (string, string) {
 	}
 }
 
+// Prefix implements PrefixMatcher: the EBML header is fixed, even though
+// which of webm/mkv it resolves to depends on a later substring search.
+func (webmOrMKVSig) Prefix() []byte { return []byte("\x1A\x45") }
+
+// offsetSig matches a fixed signature that does not start at the beginning
+// of the file, such as the "ustar" marker in a POSIX tar header.
+type offsetSig struct {
+	ext, mime string
+	offset    int
+	sig       []byte
+}
+
+func (o *offsetSig) Match(data []byte) (string, string) {
+	end := o.offset + len(o.sig)
+	if len(data) < end {
+		return "", ""
+	}
+	if bytes.Equal(data[o.offset:end], o.sig) {
+		return o.mime, o.ext
+	}
+	return "", ""
+}
+
+// zipEntrySig detects a format layered on top of the ZIP container (such as
+// EPUB or Office Open XML) by requiring the ZIP local file header and then
+// looking for a telltale byte sequence - typically the first entry's name
+// and, for EPUB, its uncompressed contents - within the sniffed prefix.
+type zipEntrySig struct {
+	ext, mime string
+	needle    string
+}
+
+func (z *zipEntrySig) Match(data []byte) (string, string) {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return "", ""
+	}
+	if bytes.Contains(data, []byte(z.needle)) {
+		return z.mime, z.ext
+	}
+	return "", ""
+}
+
+// Prefix implements PrefixMatcher: every zipEntrySig requires the same ZIP
+// local file header, same as the generic "zip" exactSig.
+func (z *zipEntrySig) Prefix() []byte { return []byte("PK\x03\x04") }
+
 type mp4Sig struct{}
 
+// mp4Brands are the additional four-byte brand codes clause 6.2.1 of the
+// WHATWG MIME Sniffing Standard requires recognizing as MP4, on top of any
+// brand that merely contains "mp4" as its first three bytes.
+var mp4Brands = [][]byte{
+	[]byte("isom"),
+	[]byte("iso2"),
+	[]byte("avc1"),
+	[]byte("mmp4"),
+	[]byte("M4V "),
+	[]byte("M4A "),
+}
+
 func (mp4Sig) Match(data []byte) (string, string) {
 	if len(data) < 12 {
 		return "", ""
@@ -155,6 +269,11 @@ func (mp4Sig) Match(data []byte) (string, string) {
 		if bytes.Equal(data[st:st+3], []byte("mp4")) {
 			return "video/mp4", "mp4"
 		}
+		for _, brand := range mp4Brands {
+			if bytes.Equal(data[st:st+4], brand) {
+				return "video/mp4", "mp4"
+			}
+		}
 	}
 	return "", ""
 }
@@ -170,9 +289,12 @@ func (u UnsupportedMIMEError) Error() string {
 
 // RegisterMatcher adds an extra magic prefix-based MIME type matcher to the
 // default set with an included canonical file extension.
+// The Matcher is not associated with any Kind, so Options.AcceptedKinds
+// restrictions never exclude it. Use RegisterKind to also classify it.
 // Not safe to use concurrently with file processing.
 func RegisterMatcher(m Matcher) {
 	matchers = append(matchers, m)
+	addToDispatch(m)
 }
 
 // RegisterProcessor registers a file processor for a specific MIME type.
@@ -182,28 +304,40 @@ func RegisterProcessor(mime string, fn Processor) {
 	mimeProcessors[mime] = fn
 }
 
-// Can be passed either the full read file as []byte or io.ReadSeeker
-func detectMimeType(buf []byte, rs io.ReadSeeker, accepted map[string]bool) (
+// Can be passed either the full read file as []byte or io.ReadSeeker.
+// acceptedKinds, if non-empty, restricts matching to Matchers registered
+// under one of the listed Kinds - see Options.AcceptedKinds.
+func detectMimeType(
+	buf []byte, rs io.ReadSeeker, accepted map[string]bool, acceptedKinds []Kind,
+) (
 	mime, ext string, err error,
 ) {
 	const size = 512
 	if buf == nil {
 		buf = make([]byte, size)
+		// rs is a pipe or network source in the general case, so a single
+		// Read can legitimately return fewer than size bytes - read until
+		// the buffer is full, the source is exhausted or an error occurs.
 		var read int
-		read, err = rs.Read(buf)
-		if err != nil {
+		read, err = io.ReadFull(rs, buf)
+		switch err {
+		case io.ErrUnexpectedEOF, io.EOF:
+			err = nil
+		case nil:
+		default:
 			return
 		}
-		if read < size {
-			buf = buf[:read]
-		}
+		buf = buf[:read]
 	} else {
 		if len(buf) > size {
 			buf = buf[:size]
 		}
 	}
 
-	for _, m := range matchers {
+	for _, m := range candidatesFor(buf) {
+		if !acceptedByKind(matcherKinds[m], acceptedKinds) {
+			continue
+		}
 		mime, ext = m.Match(buf)
 		if mime != "" {
 			break
@@ -219,6 +353,16 @@ func detectMimeType(buf []byte, rs io.ReadSeeker, accepted map[string]bool) (
 	return
 }
 
+// processFile dispatches to the Processor registered for src.Mime, if any,
+// falling back to the built-in image/audio/video processors below. Document
+// (other than application/pdf, which goes through processImage), archive and
+// font MIME types have no built-in processor: they must be registered via
+// RegisterProcessor, or processing fails with UnsupportedMIMEError. This
+// includes application/xhtml+xml: no built-in Matcher ever produces it (an
+// XHTML document sniffs as text/html or text/xml depending on how it begins,
+// or as image/svg+xml if its root element is <svg), so it isn't routed to
+// processHTML by default - register a Processor for it explicitly if XHTML
+// support is needed.
 func processFile(src Source, opts Options) (Source, Thumbnail, error) {
 	override := mimeProcessors[src.Mime]
 	if override != nil {
@@ -254,7 +398,11 @@ func processFile(src Source, opts Options) (Source, Thumbnail, error) {
 		"video/x-ms-wmv",
 		"video/x-flv":
 		return processVideo(src, opts)
+	case "image/svg+xml":
+		return processSVG(src, opts)
+	case "text/html":
+		return processHTML(src, opts)
 	default:
 		return src, Thumbnail{}, UnsupportedMIMEError(src.Mime)
 	}
-}
\ No newline at end of file
+}
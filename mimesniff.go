@@ -0,0 +1,149 @@
+package thumbnailer
+
+import "bytes"
+
+// skipLeadingWhitespace drops the leading run of ASCII whitespace bytes the
+// WHATWG MIME Sniffing Standard's pattern matching algorithm skips over
+// before comparing a resource's header against a pattern - clause 2 of
+// https://mimesniff.spec.whatwg.org/#pattern-matching-algorithm. Written as
+// a plain loop rather than bytes.TrimLeft, which rebuilds an ASCII lookup
+// table on every call - wasteful when this runs once per Matcher per
+// sniffed file.
+func skipLeadingWhitespace(data []byte) []byte {
+	i := 0
+	for i < len(data) {
+		switch data[i] {
+		case '\t', '\n', '\x0C', '\r', ' ':
+			i++
+		default:
+			return data[i:]
+		}
+	}
+	return data[i:]
+}
+
+// tagTerminatingBytes are the bytes the standard accepts as terminating an
+// HTML or XML tag name in its tag-terminated byte pattern matching
+// algorithm (https://mimesniff.spec.whatwg.org/#matching-a-tag-name-prefix).
+var tagTerminatingBytes = []byte(" >")
+
+// tagSig matches one of the case-insensitive, tag-terminated signatures the
+// standard lists for text/html (the various "<!DOCTYPE HTML", "<HTML",
+// "<SVG", ... prefixes) or, case-sensitively, for text/xml ("<?xml").
+// Leading ASCII whitespace is skipped first, per clause 2 of the pattern
+// matching algorithm, and the byte immediately after the signature must be
+// a tag-terminating byte.
+type tagSig struct {
+	ext, mime  string
+	sig        []byte // upper-cased for caseInsensitive signatures
+	ignoreCase bool
+}
+
+func (t *tagSig) Match(data []byte) (string, string) {
+	data = skipLeadingWhitespace(data)
+	if len(data) < len(t.sig)+1 {
+		return "", ""
+	}
+	for i, want := range t.sig {
+		got := data[i]
+		if t.ignoreCase && 'a' <= got && got <= 'z' {
+			got -= 'a' - 'A'
+		}
+		if got != want {
+			return "", ""
+		}
+	}
+	if !bytes.ContainsAny(data[len(t.sig):len(t.sig)+1], string(tagTerminatingBytes)) {
+		return "", ""
+	}
+	return t.mime, t.ext
+}
+
+// tagSig intentionally has no Prefix() method, and so is never filed in the
+// dispatch table: Match skips leading ASCII whitespace before comparing
+// against sig, so the signature isn't actually at data's first byte for
+// whitespace-prefixed input, and the dispatch table only ever looks at
+// data's literal first bytes. Always scanned via residualMatchers instead.
+
+// bomSig matches a Unicode byte order mark, the standard's last-resort
+// signal for the text/plain fallback.
+type bomSig struct {
+	ext, mime string
+	bom       []byte
+}
+
+func (b *bomSig) Match(data []byte) (string, string) {
+	if bytes.HasPrefix(data, b.bom) {
+		return b.mime, b.ext
+	}
+	return "", ""
+}
+
+// Prefix implements PrefixMatcher: a BOM is itself a fixed byte sequence.
+func (b *bomSig) Prefix() []byte { return b.bom }
+
+// htmlSigs are the tag-terminated text/html signatures from the standard's
+// pattern table, matched case-insensitively.
+var htmlSigs = []tagSig{
+	{"html", "text/html", []byte("<!DOCTYPE HTML"), true},
+	{"html", "text/html", []byte("<HTML"), true},
+	{"html", "text/html", []byte("<HEAD"), true},
+	{"html", "text/html", []byte("<SCRIPT"), true},
+	{"html", "text/html", []byte("<IFRAME"), true},
+	{"html", "text/html", []byte("<H1"), true},
+	{"html", "text/html", []byte("<DIV"), true},
+	{"html", "text/html", []byte("<FONT"), true},
+	{"html", "text/html", []byte("<TABLE"), true},
+	{"html", "text/html", []byte("<A"), true},
+	{"html", "text/html", []byte("<STYLE"), true},
+	{"html", "text/html", []byte("<TITLE"), true},
+	{"html", "text/html", []byte("<B"), true},
+	{"html", "text/html", []byte("<BODY"), true},
+	{"html", "text/html", []byte("<BR"), true},
+	{"html", "text/html", []byte("<P"), true},
+	{"html", "text/html", []byte("<!--"), true},
+}
+
+var xmlSig = tagSig{"xml", "text/xml", []byte("<?xml"), false}
+
+var textSigs = []bomSig{
+	{"txt", "text/plain", []byte("\xEF\xBB\xBF")}, // UTF-8 BOM
+	{"txt", "text/plain", []byte("\xFE\xFF")},     // UTF-16BE BOM
+	{"txt", "text/plain", []byte("\xFF\xFE")},     // UTF-16LE BOM
+}
+
+// DetectContentType sniffs the MIME type and canonical extension of data,
+// the way net/http.DetectContentType does for the fixed net/http table, but
+// over this package's full built-in and user-registered matcher set -
+// images, audio, video, archives, documents, fonts, and the WHATWG
+// HTML/XML/plain-text fallbacks.
+func DetectContentType(data []byte) (mime, ext string) {
+	const size = 512
+	if len(data) > size {
+		data = data[:size]
+	}
+	for _, m := range candidatesFor(data) {
+		mime, ext = m.Match(data)
+		if mime != "" {
+			return
+		}
+	}
+	return "application/octet-stream", "bin"
+}
+
+func init() {
+	for i := range htmlSigs {
+		addKindedMatcher(KindDocument, &htmlSigs[i])
+	}
+
+	// svgSig must be registered ahead of xmlSig and textSigs: an SVG
+	// preceded by an XML prologue or a BOM also matches those generic
+	// fallbacks, and registration order is what decides the tie (see
+	// svgSig's doc comment in svg.go).
+	addKindedMatcher(KindImage, svgSig{})
+
+	addKindedMatcher(KindDocument, &xmlSig)
+	for i := range textSigs {
+		addKindedMatcher(KindDocument, &textSigs[i])
+	}
+}
@@ -0,0 +1,40 @@
+package thumbnailer
+
+import (
+	"bytes"
+	"io"
+)
+
+// Sniff detects the MIME type and canonical extension of r by reading up to
+// the first 512 bytes, the same way detectMimeType does for an
+// io.ReadSeeker, but without requiring r to support Seek. The returned body
+// is an io.Reader that yields the sniffed prefix followed by the remainder
+// of r, so no bytes are lost and r itself need not be rewound - making this
+// suitable for HTTP uploads, multipart parts and other one-shot streams.
+func Sniff(r io.Reader) (mime, ext string, body io.Reader, err error) {
+	const size = 512
+	buf := make([]byte, size)
+
+	read, rerr := io.ReadFull(r, buf)
+	switch rerr {
+	case io.ErrUnexpectedEOF, io.EOF:
+		// Fewer than size bytes total - not an error, just a small file.
+	case nil:
+	default:
+		err = rerr
+		return
+	}
+	buf = buf[:read]
+	body = io.MultiReader(bytes.NewReader(buf), r)
+
+	for _, m := range candidatesFor(buf) {
+		mime, ext = m.Match(buf)
+		if mime != "" {
+			break
+		}
+	}
+	if mime == "" {
+		err = UnsupportedMIMEError("application/octet-stream")
+	}
+	return
+}
@@ -0,0 +1,111 @@
+package thumbnailer
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+// svgSig detects image/svg+xml by requiring a <svg ...> root element at the
+// start of the sniffed prefix, after skipping an optional UTF-8 BOM and XML
+// prologue (e.g. <?xml version="1.0"?>) - the mimesniff algorithm allows
+// the defining tag of an XML-based format to appear past such a preamble,
+// rather than requiring it at byte 0. <svg appearing deeper in the prefix,
+// e.g. inlined in some other XML/HTML document, doesn't count: that isn't
+// an SVG document, just one that embeds or mentions one.
+//
+// svgSig is registered from mimesniff.go's init, ahead of xmlSig and
+// textSigs: an XML-prologue-prefixed or BOM-prefixed SVG matches all three,
+// and svgSig must win the tie or every such SVG gets sniffed as text/xml or
+// text/plain instead.
+type svgSig struct{}
+
+func (svgSig) Match(data []byte) (string, string) {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	data = skipLeadingWhitespace(data)
+	if bytes.HasPrefix(data, []byte("<?xml")) {
+		if end := bytes.Index(data, []byte("?>")); end != -1 {
+			data = skipLeadingWhitespace(data[end+2:])
+		}
+	}
+
+	if indexASCIIFold(data, "<svg") != 0 {
+		return "", ""
+	}
+	rest := data[len("<svg"):]
+	if len(rest) == 0 || !bytes.ContainsAny(rest[:1], string(tagTerminatingBytes)) {
+		return "", ""
+	}
+	return "image/svg+xml", "svg"
+}
+
+// indexASCIIFold returns the index of the first ASCII case-insensitive
+// match of lower (which must already be lower-cased, and must start with a
+// byte that has no uppercase counterpart, such as "<") within data, or -1.
+// Unlike bytes.ToUpper(data), it never allocates a folded copy of data and
+// doesn't pull in unicode-aware case folding for what is always a plain
+// ASCII tag name - it instead uses bytes.IndexByte to skip straight to each
+// candidate start byte.
+func indexASCIIFold(data []byte, lower string) int {
+	if len(lower) == 0 {
+		return 0
+	}
+	for offset := 0; ; {
+		rel := bytes.IndexByte(data[offset:], lower[0])
+		if rel == -1 {
+			return -1
+		}
+		i := offset + rel
+		if i+len(lower) > len(data) {
+			return -1
+		}
+		match := true
+		for j := 1; j < len(lower); j++ {
+			c := data[i+j]
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			if c != lower[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+		offset = i + 1
+	}
+}
+
+// processSVG rasterizes the SVG in src to an in-memory PNG and hands it off
+// to the regular image pipeline, so SVGs get thumbnailed the same way any
+// other raster image would be.
+func processSVG(src Source, opts Options) (Source, Thumbnail, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(src.Data))
+	if err != nil {
+		return src, Thumbnail{}, err
+	}
+
+	w, h := int(icon.ViewBox.W), int(icon.ViewBox.H)
+	if w <= 0 || h <= 0 {
+		w, h = 512, 512
+	}
+	icon.SetTarget(0, 0, float64(w), float64(h))
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	scanner := rasterx.NewScannerGV(w, h, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(w, h, scanner), 1)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return src, Thumbnail{}, err
+	}
+
+	rendered := src
+	rendered.Mime, rendered.Extension = "image/png", "png"
+	rendered.Data = buf.Bytes()
+	return processImage(rendered, opts)
+}
@@ -0,0 +1,56 @@
+package thumbnailer
+
+import "testing"
+
+// TestCandidatesForSVGBeatsXMLAndText guards against a regression where an
+// XML-prologue-prefixed or BOM-prefixed SVG was sniffed as text/xml or
+// text/plain instead of image/svg+xml, because xmlSig and textSigs matched
+// first and the scan stopped there before svgSig ever ran.
+func TestCandidatesForSVGBeatsXMLAndText(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{
+			name: "xml prologue",
+			data: []byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"),
+		},
+		{
+			name: "UTF-8 BOM",
+			data: append([]byte("\xEF\xBB\xBF"), []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>")...),
+		},
+		{
+			name: "UTF-8 BOM and xml prologue",
+			data: append([]byte("\xEF\xBB\xBF<?xml version=\"1.0\"?>\n"), []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>")...),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var mime, ext string
+			for _, m := range candidatesFor(c.data) {
+				mime, ext = m.Match(c.data)
+				if mime != "" {
+					break
+				}
+			}
+
+			if mime != "image/svg+xml" || ext != "svg" {
+				t.Fatalf("got (%q, %q), want (\"image/svg+xml\", \"svg\")", mime, ext)
+			}
+		})
+	}
+}
+
+// TestSVGSigRequiresRootElement guards against a regression where svgSig
+// matched any prefix containing "<svg" anywhere, not just as the root
+// element - misdetecting an XML document that merely embeds or mentions an
+// <svg as image/svg+xml.
+func TestSVGSigRequiresRootElement(t *testing.T) {
+	data := []byte("<?xml version=\"1.0\"?>\n<html><body><svg></svg></body></html>")
+
+	mime, _ := svgSig{}.Match(data)
+	if mime != "" {
+		t.Fatalf("got mime %q, want no match - <svg isn't the root element", mime)
+	}
+}